@@ -0,0 +1,36 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+// EtcdCoreDNSSpec configures the in-cluster, etcd-backed CoreDNS DNS
+// provider used by upup/pkg/fi/cloudup/dns/coredns. A nil value (the
+// default) means the cluster isn't using it, even if UsePrivateDNS() is
+// true for other reasons (e.g. a private, cloud-hosted zone).
+//
+// It is wired in as ClusterSpec.EtcdCoreDNS in types.go.
+//
+// +k8s:deepcopy-gen=true
+type EtcdCoreDNSSpec struct {
+	// Endpoints are the etcd client URLs to talk to.
+	Endpoints []string `json:"endpoints,omitempty"`
+	// PathPrefix is the etcd key prefix zone data is read/written under.
+	// Defaults to coredns.DefaultPathPrefix ("/skydns") if empty.
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// Zones are the DNS zone names served out of etcd. Defaults to the
+	// cluster's own DNS name if empty.
+	Zones []string `json:"zones,omitempty"`
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import "time"
+
+// DNSValidationMode controls whether a DNS validation problem found by
+// validateDNS (upup/pkg/fi/cloudup/dns_validate.go) fails the pre-flight
+// check or is only logged as a warning.
+type DNSValidationMode string
+
+const (
+	// DNSValidationModeStrict fails validateDNS on any discrepancy (missing
+	// delegation, NS mismatch, broken DNSSEC chain).
+	DNSValidationModeStrict DNSValidationMode = "Strict"
+	// DNSValidationModePermissive (the default) logs discrepancies as
+	// warnings but does not fail validateDNS.
+	DNSValidationModePermissive DNSValidationMode = "Permissive"
+)
+
+// DNSValidationSpec configures the authoritative-nameserver/DNSSEC probe in
+// validateDNS, replacing the old DNS_IGNORE_NS_CHECK environment variable.
+//
+// It is wired in as ClusterSpec.DNSValidation in types.go.
+//
+// +k8s:deepcopy-gen=true
+type DNSValidationSpec struct {
+	// Resolvers are the DNS servers (IP, or IP:port) used to find the
+	// parent zone's authoritative nameservers. Defaults to a small public
+	// resolver set if empty.
+	Resolvers []string `json:"resolvers,omitempty"`
+	// Timeout bounds each individual DNS query made during validation.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Mode is Strict or Permissive (the default).
+	Mode DNSValidationMode `json:"mode,omitempty"`
+	// Skip disables DNS validation entirely, for private/split-horizon
+	// setups where the probe can't possibly succeed.
+	Skip bool `json:"skip,omitempty"`
+}
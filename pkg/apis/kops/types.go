@@ -0,0 +1,84 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+// ClusterSpec defines the configuration for a cluster.
+//
+// This checkout only carries the fields actually referenced by the
+// DNS-related code under upup/pkg/fi/cloudup (dns.go, dns_precreate.go,
+// dns_validate.go, dns/coredns); the rest of the real ClusterSpec lives
+// upstream and is untouched here.
+//
+// +k8s:deepcopy-gen=true
+type ClusterSpec struct {
+	// DNSZone is the DNS zone to use, if it is not inferred from the
+	// cluster name.
+	DNSZone string `json:"dnsZone,omitempty"`
+	// MasterPublicName is the external DNS name for the master nodes.
+	MasterPublicName string `json:"masterPublicName,omitempty"`
+	// MasterInternalName is the internal DNS name for the master nodes.
+	MasterInternalName string `json:"masterInternalName,omitempty"`
+	// EtcdClusters stores the configuration for each etcd cluster.
+	EtcdClusters []*EtcdClusterSpec `json:"etcdClusters,omitempty"`
+	// Topology defines the network topology for the cluster.
+	Topology *TopologySpec `json:"topology,omitempty"`
+	// EtcdCoreDNS configures the in-cluster, etcd-backed CoreDNS DNS
+	// provider used by upup/pkg/fi/cloudup/dns/coredns. A nil value (the
+	// default) means the cluster isn't using it, even if UsePrivateDNS()
+	// is true for other reasons (e.g. a private, cloud-hosted zone).
+	EtcdCoreDNS *EtcdCoreDNSSpec `json:"etcdCoreDNS,omitempty"`
+	// AdditionalSANs are extra hostnames/IPs to precreate DNS records for,
+	// alongside MasterPublicName (see additionalSANsPrecreateHostnames in
+	// upup/pkg/fi/cloudup/dns_precreate.go).
+	AdditionalSANs []string `json:"additionalSANs,omitempty"`
+	// DNSValidation configures the authoritative-nameserver/DNSSEC probe
+	// validateDNS runs as a pre-flight check (see
+	// upup/pkg/fi/cloudup/dns_validate.go).
+	DNSValidation *DNSValidationSpec `json:"dnsValidation,omitempty"`
+}
+
+// TopologySpec defines the network topology for the cluster.
+//
+// +k8s:deepcopy-gen=true
+type TopologySpec struct {
+	// Bastion describes the cluster's bastion host, if any.
+	Bastion *BastionSpec `json:"bastion,omitempty"`
+}
+
+// BastionSpec describes the cluster's bastion host.
+//
+// +k8s:deepcopy-gen=true
+type BastionSpec struct {
+}
+
+// EtcdClusterSpec is configuration for the etcd cluster.
+//
+// +k8s:deepcopy-gen=true
+type EtcdClusterSpec struct {
+	// Name is the name of the etcd cluster (main, events, ...).
+	Name string `json:"name,omitempty"`
+	// Members stores the configuration for each member of the cluster.
+	Members []*EtcdMemberSpec `json:"etcdMembers,omitempty"`
+}
+
+// EtcdMemberSpec is configuration for a single etcd cluster member.
+//
+// +k8s:deepcopy-gen=true
+type EtcdMemberSpec struct {
+	// Name is the name of the member within the etcd cluster.
+	Name string `json:"name,omitempty"`
+}
@@ -23,10 +23,10 @@ import (
 	"k8s.io/kops/pkg/featureflag"
 	"k8s.io/kops/pkg/model"
 	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/dns/coredns"
 	"k8s.io/kubernetes/federation/pkg/dnsprovider"
 	"k8s.io/kubernetes/federation/pkg/dnsprovider/rrstype"
-	"net"
-	"os"
+	"sort"
 	"strings"
 )
 
@@ -37,8 +37,46 @@ const (
 	PlaceholderTTL = 10
 )
 
+// dnsProvider returns the dnsprovider.Interface to use for the cluster: the
+// cloud's own DNS API, unless the cluster has actually opted into the
+// etcd/CoreDNS-backed private DNS provider (cluster.Spec.EtcdCoreDNS is set),
+// in which case we return that instead of talking to the cloud.
+//
+// UsePrivateDNS() alone isn't a safe enough signal to make that switch: it
+// pre-dates this provider and is also true for clusters using a private
+// (cloud-hosted) DNS zone that has nothing to do with etcd/CoreDNS, so
+// routing every UsePrivateDNS() cluster through coredns.New() would break
+// precreateDNS for all of them.
+func dnsProvider(cluster *api.Cluster, cloud fi.Cloud) (dnsprovider.Interface, error) {
+	kopsModelContext := &model.KopsModelContext{
+		Cluster: cluster,
+		// We are not initializing a lot of the fields here; revisit once UsePrivateDNS is "real"
+	}
+
+	if kopsModelContext.UsePrivateDNS() && cluster.Spec.EtcdCoreDNS != nil {
+		provider, err := coredns.New(cluster)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	}
+
+	return cloud.DNS()
+}
+
+// findZone locates the hosted zone we should use for the cluster.
+//
+// cluster.Spec.DNSZone usually names the hosted zone exactly, e.g. if the
+// cluster's DNS name is "mycluster.k8s.example.com" then DNSZone is also
+// "mycluster.k8s.example.com" and there is a hosted zone with that name.
+//
+// It is also valid for DNSZone to name a zone that is a strict parent of the
+// cluster's DNS name (e.g. DNSZone is "example.com", but the cluster lives at
+// "mycluster.k8s.example.com").  In that case we precreate records for the
+// fully-qualified cluster names directly in the parent zone, so that users
+// don't have to delegate a brand new hosted zone for every cluster.
 func findZone(cluster *api.Cluster, cloud fi.Cloud) (dnsprovider.Zone, error) {
-	dns, err := cloud.DNS()
+	dns, err := dnsProvider(cluster, cloud)
 	if err != nil {
 		return nil, fmt.Errorf("error building DNS provider: %v", err)
 	}
@@ -53,72 +91,98 @@ func findZone(cluster *api.Cluster, cloud fi.Cloud) (dnsprovider.Zone, error) {
 		return nil, fmt.Errorf("error listing DNS zones: %v", err)
 	}
 
-	var matches []dnsprovider.Zone
-	findName := strings.TrimSuffix(cluster.Spec.DNSZone, ".")
+	clusterDNSName := strings.TrimSuffix(cluster.ObjectMeta.Name, ".")
+	return selectZone(zones, clusterDNSName, cluster.Spec.DNSZone)
+}
+
+// selectZone picks the zone from zones we should use for a cluster whose
+// own DNS name is clusterDNSName and whose cluster.Spec.DNSZone is dnsZone.
+// It's a free function (rather than inlined in findZone) so the selection
+// logic can be unit-tested without a real dnsprovider.Interface.
+func selectZone(zones []dnsprovider.Zone, clusterDNSName, dnsZone string) (dnsprovider.Zone, error) {
+	findName := strings.TrimSuffix(dnsZone, ".")
+
+	// idMatches holds zones whose opaque cloud ID equals dnsZone.  An ID
+	// isn't a DNS name, so there's no suffix to compare it against - an ID
+	// match always wins immediately.
+	//
+	// exact holds zones whose Name() textually equals dnsZone; candidates
+	// holds exact plus any zone that is a strict ancestor of the cluster's
+	// own DNS name (isSubdomain).  Folding exact matches into candidates,
+	// rather than returning them immediately, lets an ancestor zone that
+	// happens to be named exactly dnsZone still compete on suffix length
+	// against a more specific ancestor, e.g. dnsZone "example.com" with
+	// both "example.com" and "k8s.example.com" registered should select
+	// "k8s.example.com".
+	var idMatches []dnsprovider.Zone
+	var exact []dnsprovider.Zone
+	var candidates []dnsprovider.Zone
 	for _, zone := range zones {
 		id := zone.ID()
 		name := strings.TrimSuffix(zone.Name(), ".")
-		if id == cluster.Spec.DNSZone || name == findName {
-			matches = append(matches, zone)
+		if id == dnsZone {
+			idMatches = append(idMatches, zone)
+			continue
+		}
+		if name == findName {
+			exact = append(exact, zone)
+			candidates = append(candidates, zone)
+			continue
+		}
+		if isSubdomain(clusterDNSName, name) {
+			candidates = append(candidates, zone)
 		}
-	}
-	if len(matches) == 0 {
-		return nil, fmt.Errorf("cannot find DNS Zone %q.  Please pre-create the zone and set up NS records so that it resolves.", cluster.Spec.DNSZone)
 	}
 
-	if len(matches) > 1 {
-		return nil, fmt.Errorf("found multiple DNS Zones matching %q", cluster.Spec.DNSZone)
+	if len(idMatches) > 1 {
+		return nil, fmt.Errorf("found multiple DNS Zones matching %q", dnsZone)
 	}
-
-	zone := matches[0]
-	return zone, nil
-}
-
-func validateDNS(cluster *api.Cluster, cloud fi.Cloud) error {
-	kopsModelContext := &model.KopsModelContext{
-		Cluster: cluster,
-		// We are not initializing a lot of the fields here; revisit once UsePrivateDNS is "real"
+	if len(idMatches) == 1 {
+		return idMatches[0], nil
 	}
 
-	if kopsModelContext.UsePrivateDNS() {
-		glog.Infof("Private DNS: skipping DNS validation")
-		return nil
+	if len(exact) > 1 {
+		return nil, fmt.Errorf("found multiple DNS Zones matching %q", dnsZone)
 	}
 
-	zone, err := findZone(cluster, cloud)
-	if err != nil {
-		return err
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("cannot find DNS Zone %q.  Please pre-create the zone and set up NS records so that it resolves.", dnsZone)
 	}
-	dnsName := strings.TrimSuffix(zone.Name(), ".")
 
-	glog.V(2).Infof("Doing DNS lookup to verify NS records for %q", dnsName)
-	ns, err := net.LookupNS(dnsName)
-	if err != nil {
-		return fmt.Errorf("error doing DNS lookup for NS records for %q: %v", dnsName, err)
-	}
+	// Several candidate zones can legitimately be hosted at once (e.g. both
+	// "k8s.example.com" and "example.com"); the most specific (longest) name
+	// is the one that's actually delegated to us, so prefer it.
+	sort.Slice(candidates, func(i, j int) bool {
+		return len(strings.TrimSuffix(candidates[i].Name(), ".")) > len(strings.TrimSuffix(candidates[j].Name(), "."))
+	})
 
-	if len(ns) == 0 {
-		if os.Getenv("DNS_IGNORE_NS_CHECK") == "" {
-			return fmt.Errorf("NS records not found for %q - please make sure they are correctly configured", dnsName)
-		} else {
-			glog.Warningf("Ignoring failed NS record check because DNS_IGNORE_NS_CHECK is set")
-		}
-	} else {
-		var hosts []string
-		for _, n := range ns {
-			hosts = append(hosts, n.Host)
-		}
-		glog.V(2).Infof("Found NS records for %q: %v", dnsName, hosts)
-	}
+	zone := candidates[0]
+	name := strings.TrimSuffix(zone.Name(), ".")
+	glog.V(2).Infof("Using DNS Zone %q for cluster DNS name %q", name, clusterDNSName)
+
+	return zone, nil
+}
 
-	return nil
+// isSubdomain returns true if name is a strict subdomain of zoneName, e.g.
+// "mycluster.k8s.example.com" is a strict subdomain of "example.com" (but
+// "example.com" is not a strict subdomain of itself).
+func isSubdomain(name, zoneName string) bool {
+	if zoneName == "" || name == zoneName {
+		return false
+	}
+	return strings.HasSuffix(name, "."+zoneName)
 }
 
-func precreateDNS(cluster *api.Cluster, cloud fi.Cloud) error {
+// precreateDNS pre-creates any DNS names the cluster will need (where they
+// don't already exist) with a dummy IP address, then returns the metrics for
+// the changeset it applied (zero-valued if nothing needed creating) so that
+// callers - ultimately the update pipeline - can log or aggregate them
+// instead of only finding them in this function's own glog output.
+func precreateDNS(cluster *api.Cluster, cloud fi.Cloud) (ChangesetMetrics, error) {
 	// TODO: Move to update
 	if !featureflag.DNSPreCreate.Enabled() {
 		glog.V(4).Infof("Skipping DNS record pre-creation because feature flag not enabled")
-		return nil
+		return ChangesetMetrics{}, nil
 	}
 
 	// We precreate some DNS names (where they don't exist), with a dummy IP address
@@ -129,24 +193,25 @@ func precreateDNS(cluster *api.Cluster, cloud fi.Cloud) error {
 
 	if len(dnsHostnames) == 0 {
 		glog.Infof("No DNS records to pre-create")
-		return nil
+		return ChangesetMetrics{}, nil
 	}
 
 	glog.Infof("Pre-creating DNS records")
 
 	zone, err := findZone(cluster, cloud)
 	if err != nil {
-		return err
+		return ChangesetMetrics{}, err
 	}
 
 	rrs, ok := zone.ResourceRecordSets()
 	if !ok {
-		return fmt.Errorf("error getting DNS resource records for %q", zone.Name())
+		return ChangesetMetrics{}, fmt.Errorf("error getting DNS resource records for %q", zone.Name())
 	}
+	rrs = newBatchedResourceRecordSets(rrs, DefaultChangesetOptions)
 
 	records, err := rrs.List()
 	if err != nil {
-		return fmt.Errorf("error listing DNS resource records for %q: %v", zone.Name(), err)
+		return ChangesetMetrics{}, fmt.Errorf("error listing DNS resource records for %q: %v", zone.Name(), err)
 	}
 
 	recordsMap := make(map[string]dnsprovider.ResourceRecordSet)
@@ -186,46 +251,19 @@ func precreateDNS(cluster *api.Cluster, cloud fi.Cloud) error {
 		created = append(created, dnsHostname)
 	}
 
+	var metrics ChangesetMetrics
 	if len(created) != 0 {
 		err := changeset.Apply()
 		if err != nil {
-			return fmt.Errorf("Error pre-creating DNS records: %v", err)
+			return ChangesetMetrics{}, fmt.Errorf("Error pre-creating DNS records: %v", err)
 		}
-		glog.V(2).Infof("Pre-created DNS names: %v", created)
-	}
-
-	return nil
-}
-
-// buildPrecreateDNSHostnames returns the hostnames we should precreate
-func buildPrecreateDNSHostnames(cluster *api.Cluster) []string {
-	dnsInternalSuffix := ".internal." + cluster.ObjectMeta.Name
-
-	var dnsHostnames []string
-
-	if cluster.Spec.MasterPublicName != "" {
-		dnsHostnames = append(dnsHostnames, cluster.Spec.MasterPublicName)
-	} else {
-		glog.Warningf("cannot pre-create MasterPublicName - not set")
-	}
-
-	if cluster.Spec.MasterInternalName != "" {
-		dnsHostnames = append(dnsHostnames, cluster.Spec.MasterInternalName)
-	} else {
-		glog.Warningf("cannot pre-create MasterInternalName - not set")
-	}
-
-	for _, etcdCluster := range cluster.Spec.EtcdClusters {
-		etcClusterName := "etcd-" + etcdCluster.Name
-		if etcdCluster.Name == "main" {
-			// Special case
-			etcClusterName = "etcd"
-		}
-		for _, etcdClusterMember := range etcdCluster.Members {
-			name := etcClusterName + "-" + etcdClusterMember.Name + dnsInternalSuffix
-			dnsHostnames = append(dnsHostnames, name)
+		if m, ok := changeset.(interface{ Metrics() ChangesetMetrics }); ok {
+			metrics = m.Metrics()
+			glog.V(2).Infof("Pre-created DNS names: %v (%d changes applied in %v)", created, metrics.ChangeCount, metrics.Elapsed)
+		} else {
+			glog.V(2).Infof("Pre-created DNS names: %v", created)
 		}
 	}
 
-	return dnsHostnames
+	return metrics, nil
 }
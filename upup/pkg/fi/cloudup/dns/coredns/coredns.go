@@ -0,0 +1,298 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package coredns implements a dnsprovider.Interface backed directly by the
+// etcd keyspace that CoreDNS's etcd plugin (and the skydns plugin before it)
+// reads from.  It lets kops bring up clusters whose master and etcd DNS
+// names live entirely inside the cluster, without needing a Route53 (or
+// other cloud) hosted zone at all.
+package coredns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/golang/glog"
+
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kubernetes/federation/pkg/dnsprovider"
+	"k8s.io/kubernetes/federation/pkg/dnsprovider/rrstype"
+)
+
+// DefaultPathPrefix is the etcd key prefix CoreDNS's etcd plugin reads its
+// zone data from by default.
+const DefaultPathPrefix = "/skydns"
+
+const requestTimeout = 10 * time.Second
+
+// Provider is a dnsprovider.Interface backed by an etcd cluster.
+type Provider struct {
+	client     *clientv3.Client
+	pathPrefix string
+	zoneNames  []string
+}
+
+var _ dnsprovider.Interface = &Provider{}
+
+// clients caches etcd clients by endpoint set, so that New - which
+// dnsProvider in upup/pkg/fi/cloudup/dns.go calls fresh on every
+// findZone/precreateDNS/validateDNS invocation - reuses a single connection
+// per etcd cluster instead of leaking a new one on every call.
+var (
+	clientsMutex sync.Mutex
+	clients      = make(map[string]*clientv3.Client)
+)
+
+// clientFor returns the cached etcd client for endpoints, dialing (and
+// caching) a new one the first time this particular endpoint set is seen.
+func clientFor(endpoints []string) (*clientv3.Client, error) {
+	key := strings.Join(endpoints, ",")
+
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+
+	if client, ok := clients[key]; ok {
+		return client, nil
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: requestTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	clients[key] = client
+	return client, nil
+}
+
+// New builds a Provider from the etcd endpoints and zone list declared on
+// the cluster spec.
+func New(cluster *api.Cluster) (*Provider, error) {
+	etcdConfig := cluster.Spec.EtcdCoreDNS
+	if etcdConfig == nil || len(etcdConfig.Endpoints) == 0 {
+		return nil, fmt.Errorf("cluster does not have EtcdCoreDNS configured; cannot use a private (in-cluster) DNS provider")
+	}
+
+	pathPrefix := etcdConfig.PathPrefix
+	if pathPrefix == "" {
+		pathPrefix = DefaultPathPrefix
+	}
+
+	client, err := clientFor(etcdConfig.Endpoints)
+	if err != nil {
+		return nil, fmt.Errorf("error building etcd client for %v: %v", etcdConfig.Endpoints, err)
+	}
+
+	zoneNames := etcdConfig.Zones
+	if len(zoneNames) == 0 {
+		zoneNames = []string{strings.TrimSuffix(cluster.ObjectMeta.Name, ".")}
+	}
+
+	return &Provider{
+		client:     client,
+		pathPrefix: pathPrefix,
+		zoneNames:  zoneNames,
+	}, nil
+}
+
+// Zones implements dnsprovider.Interface.
+func (p *Provider) Zones() (dnsprovider.Zones, bool) {
+	return &zones{provider: p}, true
+}
+
+type zones struct {
+	provider *Provider
+}
+
+var _ dnsprovider.Zones = &zones{}
+
+// List implements dnsprovider.Zones.
+func (z *zones) List() ([]dnsprovider.Zone, error) {
+	var result []dnsprovider.Zone
+	for _, name := range z.provider.zoneNames {
+		result = append(result, &zone{provider: z.provider, name: name})
+	}
+	return result, nil
+}
+
+type zone struct {
+	provider *Provider
+	name     string
+}
+
+var _ dnsprovider.Zone = &zone{}
+
+// Name implements dnsprovider.Zone.
+func (z *zone) Name() string {
+	return z.name
+}
+
+// ID implements dnsprovider.Zone.
+func (z *zone) ID() string {
+	return z.name
+}
+
+// ResourceRecordSets implements dnsprovider.Zone.
+func (z *zone) ResourceRecordSets() (dnsprovider.ResourceRecordSets, bool) {
+	return &resourceRecordSets{zone: z}, true
+}
+
+type resourceRecordSets struct {
+	zone *zone
+}
+
+var _ dnsprovider.ResourceRecordSets = &resourceRecordSets{}
+
+// etcdRecord mirrors the JSON value format used by skydns/CoreDNS's etcd
+// plugin: https://coredns.io/plugins/etcd/
+type etcdRecord struct {
+	Host string `json:"host"`
+	TTL  int64  `json:"ttl,omitempty"`
+}
+
+// etcdKey maps a fully-qualified DNS name to the reversed-label etcd key
+// that CoreDNS's etcd plugin expects, e.g. "api.mycluster.k8s.example.com"
+// becomes "/skydns/com/example/k8s/mycluster/api".
+func (r *resourceRecordSets) etcdKey(dnsName string) string {
+	dnsName = strings.TrimSuffix(dnsName, ".")
+	labels := strings.Split(dnsName, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return r.zone.provider.pathPrefix + "/" + strings.Join(labels, "/")
+}
+
+// List implements dnsprovider.ResourceRecordSets.
+func (r *resourceRecordSets) List() ([]dnsprovider.ResourceRecordSet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	prefix := r.etcdKey(r.zone.name)
+	resp, err := r.zone.provider.client.Get(ctx, prefix+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("error listing etcd keys under %q: %v", prefix, err)
+	}
+
+	var records []dnsprovider.ResourceRecordSet
+	for _, kv := range resp.Kvs {
+		var rec etcdRecord
+		if err := json.Unmarshal(kv.Value, &rec); err != nil {
+			glog.Warningf("ignoring unparseable etcd DNS record at %q: %v", string(kv.Key), err)
+			continue
+		}
+		if rec.Host == "" {
+			continue
+		}
+		records = append(records, &resourceRecordSet{
+			name:    r.keyToName(string(kv.Key)),
+			rrdatas: []string{rec.Host},
+			ttl:     rec.TTL,
+			rrsType: rrstype.A,
+		})
+	}
+	return records, nil
+}
+
+// keyToName is the inverse of etcdKey.
+func (r *resourceRecordSets) keyToName(key string) string {
+	key = strings.TrimPrefix(key, r.zone.provider.pathPrefix+"/")
+	labels := strings.Split(key, "/")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, ".")
+}
+
+// New implements dnsprovider.ResourceRecordSets.
+func (r *resourceRecordSets) New(name string, rrdatas []string, ttl int64, rrsType rrstype.RrsType) dnsprovider.ResourceRecordSet {
+	return &resourceRecordSet{name: name, rrdatas: rrdatas, ttl: ttl, rrsType: rrsType}
+}
+
+// StartChangeset implements dnsprovider.ResourceRecordSets.
+func (r *resourceRecordSets) StartChangeset() dnsprovider.ResourceRecordChangeset {
+	return &changeset{rrs: r}
+}
+
+type resourceRecordSet struct {
+	name    string
+	rrdatas []string
+	ttl     int64
+	rrsType rrstype.RrsType
+}
+
+var _ dnsprovider.ResourceRecordSet = &resourceRecordSet{}
+
+func (r *resourceRecordSet) Name() string          { return r.name }
+func (r *resourceRecordSet) Rrdatas() []string     { return r.rrdatas }
+func (r *resourceRecordSet) Ttl() int64            { return r.ttl }
+func (r *resourceRecordSet) Type() rrstype.RrsType { return r.rrsType }
+
+type changeset struct {
+	rrs       *resourceRecordSets
+	additions []dnsprovider.ResourceRecordSet
+	removals  []dnsprovider.ResourceRecordSet
+}
+
+var _ dnsprovider.ResourceRecordChangeset = &changeset{}
+
+// Add implements dnsprovider.ResourceRecordChangeset.
+func (c *changeset) Add(rrs dnsprovider.ResourceRecordSet) dnsprovider.ResourceRecordChangeset {
+	c.additions = append(c.additions, rrs)
+	return c
+}
+
+// Remove implements dnsprovider.ResourceRecordChangeset.
+func (c *changeset) Remove(rrs dnsprovider.ResourceRecordSet) dnsprovider.ResourceRecordChangeset {
+	c.removals = append(c.removals, rrs)
+	return c
+}
+
+// Apply implements dnsprovider.ResourceRecordChangeset.
+func (c *changeset) Apply() error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	client := c.rrs.zone.provider.client
+
+	for _, rrs := range c.additions {
+		if len(rrs.Rrdatas()) == 0 {
+			continue
+		}
+		value, err := json.Marshal(etcdRecord{Host: rrs.Rrdatas()[0], TTL: rrs.Ttl()})
+		if err != nil {
+			return fmt.Errorf("error marshaling etcd DNS record for %q: %v", rrs.Name(), err)
+		}
+		key := c.rrs.etcdKey(rrs.Name())
+		if _, err := client.Put(ctx, key, string(value)); err != nil {
+			return fmt.Errorf("error writing etcd DNS record %q: %v", key, err)
+		}
+	}
+
+	for _, rrs := range c.removals {
+		key := c.rrs.etcdKey(rrs.Name())
+		if _, err := client.Delete(ctx, key); err != nil {
+			return fmt.Errorf("error deleting etcd DNS record %q: %v", key, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package coredns
+
+import (
+	"testing"
+
+	"github.com/coreos/etcd/clientv3"
+)
+
+func TestEtcdKeyAndKeyToNameRoundTrip(t *testing.T) {
+	rrs := &resourceRecordSets{
+		zone: &zone{
+			provider: &Provider{pathPrefix: DefaultPathPrefix},
+		},
+	}
+
+	grid := []struct {
+		name string
+		key  string
+	}{
+		{"api.mycluster.k8s.example.com", "/skydns/com/example/k8s/mycluster/api"},
+		{"api.mycluster.k8s.example.com.", "/skydns/com/example/k8s/mycluster/api"},
+		{"mycluster.k8s.example.com", "/skydns/com/example/k8s/mycluster"},
+	}
+
+	for _, g := range grid {
+		if actual := rrs.etcdKey(g.name); actual != g.key {
+			t.Errorf("etcdKey(%q) = %q, want %q", g.name, actual, g.key)
+		}
+	}
+
+	for _, g := range grid {
+		want := g.name
+		if want[len(want)-1] == '.' {
+			want = want[:len(want)-1]
+		}
+		if actual := rrs.keyToName(g.key); actual != want {
+			t.Errorf("keyToName(%q) = %q, want %q", g.key, actual, want)
+		}
+	}
+}
+
+func TestClientForReusesClientPerEndpointSet(t *testing.T) {
+	clientsMutex.Lock()
+	clients = make(map[string]*clientv3.Client)
+	clientsMutex.Unlock()
+
+	a, err := clientFor([]string{"127.0.0.1:2379"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, err := clientFor([]string{"127.0.0.1:2379"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a != b {
+		t.Errorf("clientFor returned different clients for the same endpoint set")
+	}
+
+	c, err := clientFor([]string{"127.0.0.1:2380"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a == c {
+		t.Errorf("clientFor returned the same client for different endpoint sets")
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package designate implements a dnsprovider.Interface backed by OpenStack
+// Designate, so that findZone/validateDNS/precreateDNS in
+// upup/pkg/fi/cloudup/dns.go work unchanged for clusters deployed on
+// OpenStack.  The OpenStack fi.Cloud implementation's DNS() method should
+// return a Provider built with New.
+package designate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/dns/v2/recordsets"
+	"github.com/gophercloud/gophercloud/openstack/dns/v2/zones"
+	"github.com/gophercloud/gophercloud/pagination"
+
+	"k8s.io/kubernetes/federation/pkg/dnsprovider"
+	"k8s.io/kubernetes/federation/pkg/dnsprovider/rrstype"
+)
+
+// pendingPollInterval/pendingPollTimeout bound how long we wait for a
+// Designate record set to move out of PENDING, after we issue a change.
+const (
+	pendingPollInterval = 2 * time.Second
+	pendingPollTimeout  = 2 * time.Minute
+)
+
+// Provider is a dnsprovider.Interface backed by an OpenStack Designate
+// service.
+type Provider struct {
+	client *gophercloud.ServiceClient
+}
+
+var _ dnsprovider.Interface = &Provider{}
+
+// New builds a Provider, authenticating from the standard OS_* environment
+// variables via gophercloud's AuthOptionsFromEnv.  clouds.yaml is not read.
+func New() (*Provider, error) {
+	authOpts, err := openstack.AuthOptionsFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("error building OpenStack auth options from environment: %v", err)
+	}
+
+	provider, err := openstack.AuthenticatedClient(authOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error authenticating with OpenStack: %v", err)
+	}
+
+	client, err := openstack.NewDNSV2(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("error building OpenStack Designate client: %v", err)
+	}
+
+	return &Provider{client: client}, nil
+}
+
+// Zones implements dnsprovider.Interface.
+func (p *Provider) Zones() (dnsprovider.Zones, bool) {
+	return &dZones{provider: p}, true
+}
+
+type dZones struct {
+	provider *Provider
+}
+
+var _ dnsprovider.Zones = &dZones{}
+
+// List implements dnsprovider.Zones.
+func (z *dZones) List() ([]dnsprovider.Zone, error) {
+	var result []dnsprovider.Zone
+
+	pager := zones.List(z.provider.client, zones.ListOpts{})
+	err := pager.EachPage(func(page pagination.Page) (bool, error) {
+		zs, err := zones.ExtractZones(page)
+		if err != nil {
+			return false, err
+		}
+		for i := range zs {
+			result = append(result, &dZone{provider: z.provider, zone: zs[i]})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing Designate zones: %v", err)
+	}
+
+	return result, nil
+}
+
+type dZone struct {
+	provider *Provider
+	zone     zones.Zone
+}
+
+var _ dnsprovider.Zone = &dZone{}
+
+// Name implements dnsprovider.Zone.
+func (z *dZone) Name() string {
+	return z.zone.Name
+}
+
+// ID implements dnsprovider.Zone.
+func (z *dZone) ID() string {
+	return z.zone.ID
+}
+
+// ResourceRecordSets implements dnsprovider.Zone.
+func (z *dZone) ResourceRecordSets() (dnsprovider.ResourceRecordSets, bool) {
+	return &dResourceRecordSets{zone: z}, true
+}
+
+type dResourceRecordSets struct {
+	zone *dZone
+}
+
+var _ dnsprovider.ResourceRecordSets = &dResourceRecordSets{}
+
+// List implements dnsprovider.ResourceRecordSets.
+func (r *dResourceRecordSets) List() ([]dnsprovider.ResourceRecordSet, error) {
+	var result []dnsprovider.ResourceRecordSet
+
+	// No Type filter: callers (e.g. the NS-delegation check in validateDNS)
+	// rely on List() returning every record set in the zone, not just A
+	// records.
+	pager := recordsets.ListByZone(r.zone.provider.client, r.zone.zone.ID, recordsets.ListOpts{})
+	err := pager.EachPage(func(page pagination.Page) (bool, error) {
+		rrs, err := recordsets.ExtractRecordSets(page)
+		if err != nil {
+			return false, err
+		}
+		for i := range rrs {
+			result = append(result, &dResourceRecordSet{zone: r.zone, recordSet: rrs[i]})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing Designate record sets for zone %q: %v", r.zone.Name(), err)
+	}
+
+	return result, nil
+}
+
+// New implements dnsprovider.ResourceRecordSets.
+func (r *dResourceRecordSets) New(name string, rrdatas []string, ttl int64, rrsType rrstype.RrsType) dnsprovider.ResourceRecordSet {
+	return &dResourceRecordSet{
+		zone: r.zone,
+		recordSet: recordsets.RecordSet{
+			Name:    strings.TrimSuffix(name, ".") + ".",
+			Records: rrdatas,
+			TTL:     int(ttl),
+			Type:    string(rrsType),
+		},
+	}
+}
+
+// StartChangeset implements dnsprovider.ResourceRecordSets.
+func (r *dResourceRecordSets) StartChangeset() dnsprovider.ResourceRecordChangeset {
+	return &dChangeset{rrs: r}
+}
+
+type dResourceRecordSet struct {
+	zone      *dZone
+	recordSet recordsets.RecordSet
+}
+
+var _ dnsprovider.ResourceRecordSet = &dResourceRecordSet{}
+
+func (r *dResourceRecordSet) Name() string      { return r.recordSet.Name }
+func (r *dResourceRecordSet) Rrdatas() []string { return r.recordSet.Records }
+func (r *dResourceRecordSet) Ttl() int64        { return int64(r.recordSet.TTL) }
+func (r *dResourceRecordSet) Type() rrstype.RrsType {
+	return rrstype.RrsType(r.recordSet.Type)
+}
+
+type dChangeset struct {
+	rrs       *dResourceRecordSets
+	additions []*dResourceRecordSet
+	removals  []*dResourceRecordSet
+}
+
+var _ dnsprovider.ResourceRecordChangeset = &dChangeset{}
+
+// Add implements dnsprovider.ResourceRecordChangeset.
+func (c *dChangeset) Add(rrs dnsprovider.ResourceRecordSet) dnsprovider.ResourceRecordChangeset {
+	c.additions = append(c.additions, rrs.(*dResourceRecordSet))
+	return c
+}
+
+// Remove implements dnsprovider.ResourceRecordChangeset.
+func (c *dChangeset) Remove(rrs dnsprovider.ResourceRecordSet) dnsprovider.ResourceRecordChangeset {
+	c.removals = append(c.removals, rrs.(*dResourceRecordSet))
+	return c
+}
+
+// Apply implements dnsprovider.ResourceRecordChangeset.  Designate record
+// set creation is asynchronous (status goes PENDING -> ACTIVE), so we poll
+// each created record set until it's ACTIVE before returning, to match the
+// synchronous contract precreateDNS expects from changeset.Apply().
+func (c *dChangeset) Apply() error {
+	client := c.rrs.zone.provider.client
+	zoneID := c.rrs.zone.zone.ID
+
+	var pending []string
+
+	for _, rrs := range c.additions {
+		created, err := recordsets.Create(client, zoneID, recordsets.CreateOpts{
+			Name:    rrs.recordSet.Name,
+			Type:    rrs.recordSet.Type,
+			TTL:     rrs.recordSet.TTL,
+			Records: rrs.recordSet.Records,
+		}).Extract()
+		if err != nil {
+			return fmt.Errorf("error creating Designate record set %q: %v", rrs.Name(), err)
+		}
+		if created.Status != "ACTIVE" {
+			pending = append(pending, created.ID)
+		}
+	}
+
+	for _, rrs := range c.removals {
+		id, err := findRecordSetID(client, zoneID, rrs.recordSet.Name, rrs.recordSet.Type)
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			continue
+		}
+		if err := recordsets.Delete(client, zoneID, id).ExtractErr(); err != nil {
+			return fmt.Errorf("error deleting Designate record set %q: %v", rrs.Name(), err)
+		}
+	}
+
+	return waitForActive(client, zoneID, pending)
+}
+
+func findRecordSetID(client *gophercloud.ServiceClient, zoneID, name, rrType string) (string, error) {
+	var id string
+	pager := recordsets.ListByZone(client, zoneID, recordsets.ListOpts{Name: name, Type: rrType})
+	err := pager.EachPage(func(page pagination.Page) (bool, error) {
+		rrs, err := recordsets.ExtractRecordSets(page)
+		if err != nil {
+			return false, err
+		}
+		for _, rr := range rrs {
+			if rr.Name == name {
+				id = rr.ID
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	return id, err
+}
+
+// waitForActive polls the given record sets until each is ACTIVE.  It returns
+// an error if one leaves PENDING for any other status (e.g. ERROR), or if
+// pendingPollTimeout elapses first.
+func waitForActive(client *gophercloud.ServiceClient, zoneID string, ids []string) error {
+	deadline := time.Now().Add(pendingPollTimeout)
+
+	for _, id := range ids {
+		for {
+			rrs, err := recordsets.Get(client, zoneID, id).Extract()
+			if err != nil {
+				return fmt.Errorf("error polling Designate record set %q: %v", id, err)
+			}
+			if rrs.Status == "ACTIVE" {
+				break
+			}
+			if rrs.Status != "PENDING" {
+				return fmt.Errorf("Designate record set %q has status %q, not ACTIVE", id, rrs.Status)
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("timed out waiting for Designate record set %q to become ACTIVE", id)
+			}
+			glog.V(4).Infof("Designate record set %q still PENDING, waiting", id)
+			time.Sleep(pendingPollInterval)
+		}
+	}
+
+	return nil
+}
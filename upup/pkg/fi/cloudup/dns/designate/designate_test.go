@@ -0,0 +1,82 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package designate
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	th "github.com/gophercloud/gophercloud/testhelper"
+	thclient "github.com/gophercloud/gophercloud/testhelper/client"
+)
+
+func TestFindRecordSetIDReturnsMatchByName(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/zones/zone-1/recordsets", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		fmt.Fprint(w, `{"recordsets": [{"id": "rrset-1", "name": "api.example.com.", "type": "A"}]}`)
+	})
+
+	id, err := findRecordSetID(thclient.ServiceClient(), "zone-1", "api.example.com.", "A")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "rrset-1" {
+		t.Errorf("got id %q, want \"rrset-1\"", id)
+	}
+}
+
+func TestFindRecordSetIDReturnsEmptyWhenNotFound(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/zones/zone-1/recordsets", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		fmt.Fprint(w, `{"recordsets": []}`)
+	})
+
+	id, err := findRecordSetID(thclient.ServiceClient(), "zone-1", "api.example.com.", "A")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "" {
+		t.Errorf("got id %q, want empty", id)
+	}
+}
+
+func TestWaitForActiveSkipsAlreadyActiveRecords(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/zones/zone-1/recordsets/rrset-1", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		fmt.Fprint(w, `{"id": "rrset-1", "status": "ACTIVE"}`)
+	})
+
+	if err := waitForActive(thclient.ServiceClient(), "zone-1", []string{"rrset-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWaitForActiveWithNoPendingIDsIsANoop(t *testing.T) {
+	if err := waitForActive(nil, "zone-1", nil); err != nil {
+		t.Fatalf("unexpected error for an empty pending list: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudup
+
+import (
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/kubernetes/federation/pkg/dnsprovider"
+	"k8s.io/kubernetes/federation/pkg/dnsprovider/rrstype"
+)
+
+// ChangesetOptions controls how a dnsprovider.ResourceRecordChangeset is
+// applied: how many changes we send per API call, and how we retry when the
+// provider throttles us.
+type ChangesetOptions struct {
+	// BatchSize is the maximum number of record-set changes (additions plus
+	// removals) sent in a single Apply call.  0 means "apply everything in
+	// one call", matching the historical behavior.
+	BatchSize int
+	// MaxBatchBytes is the maximum serialized size (the record name plus
+	// its rrdatas, summed across every change) of a single Apply call.  It
+	// is enforced alongside BatchSize, not instead of it: a batch under
+	// BatchSize can still be oversized if its rrdatas are large (e.g. TXT
+	// records, or A records with many values), and a batch is cut short
+	// once adding the next change would exceed MaxBatchBytes, even if
+	// BatchSize hasn't been reached yet.  A batch always contains at least
+	// one change, even if that change alone exceeds MaxBatchBytes, so we
+	// keep making progress.  0 means "unbounded".
+	MaxBatchBytes int
+	// MaxRetries is the number of times we retry a batch after a throttling
+	// error before giving up.
+	MaxRetries int
+	// Backoff is the initial delay before the first retry; it doubles after
+	// each subsequent retry of the same batch.
+	Backoff time.Duration
+}
+
+// DefaultChangesetOptions are the options precreateDNS uses: batches of at
+// most 500 changes (half of Route53's 1000-change per-request limit) and at
+// most 32000 estimated characters (Route53's per-request character limit),
+// with a handful of exponential-backoff retries on throttling.
+var DefaultChangesetOptions = ChangesetOptions{
+	BatchSize:     500,
+	MaxBatchBytes: 32000,
+	MaxRetries:    5,
+	Backoff:       1 * time.Second,
+}
+
+// ChangesetMetrics summarizes a completed batchedChangeset.Apply call: how
+// many individual record-set changes were sent (after batching) and how
+// long the whole operation took wall-clock.  It's exposed as a separate
+// accessor (see batchedChangeset.Metrics) rather than returned from Apply,
+// since Apply's signature is fixed by dnsprovider.ResourceRecordChangeset.
+type ChangesetMetrics struct {
+	ChangeCount int
+	Elapsed     time.Duration
+}
+
+// throttlingErrorSubstrings are matched (case-sensitively, as substrings)
+// against an Apply error to decide whether it's worth retrying.  The
+// dnsprovider interface only gives us an error, not a typed/structured
+// error, so this is necessarily a little fuzzy.
+var throttlingErrorSubstrings = []string{
+	"Throttling",
+	"PriorRequestNotComplete",
+	"RequestLimitExceeded",
+	"rateLimitExceeded", // googleapi
+}
+
+func isThrottlingError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, substr := range throttlingErrorSubstrings {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// newBatchedResourceRecordSets wraps rrs so that changesets built from it
+// are split into batches of at most opts.BatchSize changes, and each batch
+// is retried with exponential backoff if the provider reports throttling.
+func newBatchedResourceRecordSets(rrs dnsprovider.ResourceRecordSets, opts ChangesetOptions) dnsprovider.ResourceRecordSets {
+	return &batchedResourceRecordSets{rrs: rrs, opts: opts}
+}
+
+type batchedResourceRecordSets struct {
+	rrs  dnsprovider.ResourceRecordSets
+	opts ChangesetOptions
+}
+
+var _ dnsprovider.ResourceRecordSets = &batchedResourceRecordSets{}
+
+func (b *batchedResourceRecordSets) List() ([]dnsprovider.ResourceRecordSet, error) {
+	return b.rrs.List()
+}
+
+func (b *batchedResourceRecordSets) New(name string, rrdatas []string, ttl int64, rrsType rrstype.RrsType) dnsprovider.ResourceRecordSet {
+	return b.rrs.New(name, rrdatas, ttl, rrsType)
+}
+
+func (b *batchedResourceRecordSets) StartChangeset() dnsprovider.ResourceRecordChangeset {
+	return &batchedChangeset{rrs: b.rrs, opts: b.opts, changeset: b.rrs.StartChangeset()}
+}
+
+// batchedChangeset buffers Add/Remove calls and, on Apply, replays them
+// against fresh changesets in opts.BatchSize-sized groups, retrying each
+// group on a throttling error.
+type batchedChangeset struct {
+	rrs       dnsprovider.ResourceRecordSets
+	opts      ChangesetOptions
+	changeset dnsprovider.ResourceRecordChangeset
+
+	additions []dnsprovider.ResourceRecordSet
+	removals  []dnsprovider.ResourceRecordSet
+
+	lastMetrics ChangesetMetrics
+}
+
+// Metrics returns the ChangesetMetrics recorded by the most recent Apply
+// call, so a caller can log (or otherwise report) them without needing to
+// watch glog output.
+func (c *batchedChangeset) Metrics() ChangesetMetrics {
+	return c.lastMetrics
+}
+
+var _ dnsprovider.ResourceRecordChangeset = &batchedChangeset{}
+
+func (c *batchedChangeset) Add(rrs dnsprovider.ResourceRecordSet) dnsprovider.ResourceRecordChangeset {
+	c.additions = append(c.additions, rrs)
+	return c
+}
+
+func (c *batchedChangeset) Remove(rrs dnsprovider.ResourceRecordSet) dnsprovider.ResourceRecordChangeset {
+	c.removals = append(c.removals, rrs)
+	return c
+}
+
+func (c *batchedChangeset) Apply() error {
+	batchSize := c.opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = len(c.additions) + len(c.removals)
+		if batchSize == 0 {
+			return nil
+		}
+	}
+
+	start := time.Now()
+	changeCount := 0
+
+	for len(c.additions) > 0 || len(c.removals) > 0 {
+		additions, removals := c.nextBatch(batchSize)
+
+		if err := c.applyBatch(additions, removals); err != nil {
+			return err
+		}
+		changeCount += len(additions) + len(removals)
+	}
+
+	c.lastMetrics = ChangesetMetrics{ChangeCount: changeCount, Elapsed: time.Since(start)}
+	glog.V(2).Infof("Applied %d DNS record changes in %v", c.lastMetrics.ChangeCount, c.lastMetrics.Elapsed)
+	return nil
+}
+
+// nextBatch pops up to batchSize changes (additions first, then removals)
+// off the front of the pending lists, also stopping early once the batch's
+// serialized size would exceed opts.MaxBatchBytes.  A batch always gets at
+// least one change, so an oversized single change can't stall progress.
+func (c *batchedChangeset) nextBatch(batchSize int) (additions, removals []dnsprovider.ResourceRecordSet) {
+	maxBytes := c.opts.MaxBatchBytes
+	size := 0
+
+	remaining := batchSize
+	for remaining > 0 && len(c.additions) > 0 {
+		next := changesetEntrySize(c.additions[0])
+		if maxBytes > 0 && size > 0 && size+next > maxBytes {
+			break
+		}
+		additions = append(additions, c.additions[0])
+		c.additions = c.additions[1:]
+		remaining--
+		size += next
+	}
+
+	for remaining > 0 && len(c.removals) > 0 {
+		next := changesetEntrySize(c.removals[0])
+		if maxBytes > 0 && size > 0 && size+next > maxBytes {
+			break
+		}
+		removals = append(removals, c.removals[0])
+		c.removals = c.removals[1:]
+		remaining--
+		size += next
+	}
+
+	return additions, removals
+}
+
+// changesetEntrySize estimates the serialized size (in characters) of a
+// single record-set change: the record name plus each of its rrdatas.  This
+// is what counts against Route53's per-request 32000-character ceiling.
+func changesetEntrySize(rrs dnsprovider.ResourceRecordSet) int {
+	size := len(rrs.Name())
+	for _, rrdata := range rrs.Rrdatas() {
+		size += len(rrdata)
+	}
+	return size
+}
+
+func (c *batchedChangeset) applyBatch(additions, removals []dnsprovider.ResourceRecordSet) error {
+	var err error
+	backoff := c.opts.Backoff
+
+	for attempt := 0; ; attempt++ {
+		changeset := c.rrs.StartChangeset()
+		for _, rrs := range additions {
+			changeset.Add(rrs)
+		}
+		for _, rrs := range removals {
+			changeset.Remove(rrs)
+		}
+
+		err = changeset.Apply()
+		if err == nil {
+			return nil
+		}
+
+		if !isThrottlingError(err) || attempt >= c.opts.MaxRetries {
+			return err
+		}
+
+		glog.Infof("DNS provider throttled batch of %d changes, retrying in %v: %v", len(additions)+len(removals), backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudup
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/federation/pkg/dnsprovider"
+	"k8s.io/kubernetes/federation/pkg/dnsprovider/rrstype"
+)
+
+func TestIsThrottlingError(t *testing.T) {
+	grid := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{errors.New("Throttling: Rate exceeded"), true},
+		{errors.New("PriorRequestNotComplete: still processing"), true},
+		{errors.New("googleapi: Error 403: rateLimitExceeded"), true},
+		{errors.New("NoSuchHostedZone: not found"), false},
+	}
+
+	for _, g := range grid {
+		if actual := isThrottlingError(g.err); actual != g.expected {
+			t.Errorf("isThrottlingError(%v) = %v, want %v", g.err, actual, g.expected)
+		}
+	}
+}
+
+// fakeRecord is a minimal dnsprovider.ResourceRecordSet for tests that don't
+// care about its contents, only its identity (and, for size-capped
+// batching, its rrdatas).
+type fakeRecord struct {
+	name    string
+	rrdatas []string
+}
+
+var _ dnsprovider.ResourceRecordSet = &fakeRecord{}
+
+func (r *fakeRecord) Name() string          { return r.name }
+func (r *fakeRecord) Rrdatas() []string     { return r.rrdatas }
+func (r *fakeRecord) Ttl() int64            { return 0 }
+func (r *fakeRecord) Type() rrstype.RrsType { return rrstype.A }
+
+// fakeRRS is a dnsprovider.ResourceRecordSets whose changesets fail with a
+// throttling error `failures` times before succeeding, recording the size of
+// each successful batch in `applies`.
+type fakeRRS struct {
+	failures int
+	applies  []int
+}
+
+var _ dnsprovider.ResourceRecordSets = &fakeRRS{}
+
+func (f *fakeRRS) List() ([]dnsprovider.ResourceRecordSet, error) { return nil, nil }
+
+func (f *fakeRRS) New(name string, rrdatas []string, ttl int64, rrsType rrstype.RrsType) dnsprovider.ResourceRecordSet {
+	return &fakeRecord{name: name}
+}
+
+func (f *fakeRRS) StartChangeset() dnsprovider.ResourceRecordChangeset {
+	return &fakeChangeset{rrs: f}
+}
+
+type fakeChangeset struct {
+	rrs       *fakeRRS
+	additions []dnsprovider.ResourceRecordSet
+	removals  []dnsprovider.ResourceRecordSet
+}
+
+var _ dnsprovider.ResourceRecordChangeset = &fakeChangeset{}
+
+func (c *fakeChangeset) Add(rrs dnsprovider.ResourceRecordSet) dnsprovider.ResourceRecordChangeset {
+	c.additions = append(c.additions, rrs)
+	return c
+}
+
+func (c *fakeChangeset) Remove(rrs dnsprovider.ResourceRecordSet) dnsprovider.ResourceRecordChangeset {
+	c.removals = append(c.removals, rrs)
+	return c
+}
+
+func (c *fakeChangeset) Apply() error {
+	if c.rrs.failures > 0 {
+		c.rrs.failures--
+		return errors.New("Throttling: Rate exceeded")
+	}
+	c.rrs.applies = append(c.rrs.applies, len(c.additions)+len(c.removals))
+	return nil
+}
+
+func recordsNamed(prefix string, n int) []dnsprovider.ResourceRecordSet {
+	var records []dnsprovider.ResourceRecordSet
+	for i := 0; i < n; i++ {
+		records = append(records, &fakeRecord{name: fmt.Sprintf("%s%d", prefix, i)})
+	}
+	return records
+}
+
+func TestBatchedChangesetNextBatch(t *testing.T) {
+	c := &batchedChangeset{
+		additions: recordsNamed("a", 5),
+		removals:  recordsNamed("r", 3),
+	}
+
+	additions, removals := c.nextBatch(6)
+	if len(additions) != 5 {
+		t.Errorf("got %d additions in first batch, want 5", len(additions))
+	}
+	if len(removals) != 1 {
+		t.Errorf("got %d removals in first batch, want 1", len(removals))
+	}
+	if len(c.additions) != 0 || len(c.removals) != 2 {
+		t.Errorf("got %d pending additions and %d pending removals, want 0 and 2", len(c.additions), len(c.removals))
+	}
+}
+
+func TestBatchedChangesetApplySplitsIntoBatches(t *testing.T) {
+	rrs := &fakeRRS{}
+	c := &batchedChangeset{
+		rrs:       rrs,
+		opts:      ChangesetOptions{BatchSize: 2},
+		additions: recordsNamed("a", 5),
+	}
+
+	if err := c.Apply(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(rrs.applies, []int{2, 2, 1}) {
+		t.Errorf("got batches %v, want [2 2 1]", rrs.applies)
+	}
+}
+
+func TestBatchedChangesetApplyRetriesOnThrottling(t *testing.T) {
+	rrs := &fakeRRS{failures: 2}
+	c := &batchedChangeset{
+		rrs:       rrs,
+		opts:      ChangesetOptions{BatchSize: 10, MaxRetries: 3, Backoff: time.Millisecond},
+		additions: recordsNamed("a", 1),
+	}
+
+	if err := c.Apply(); err != nil {
+		t.Fatalf("unexpected error after throttling retries: %v", err)
+	}
+	if !reflect.DeepEqual(rrs.applies, []int{1}) {
+		t.Errorf("got batches %v, want a single successful batch of size 1", rrs.applies)
+	}
+}
+
+func TestBatchedChangesetNextBatchRespectsMaxBatchBytes(t *testing.T) {
+	c := &batchedChangeset{
+		opts: ChangesetOptions{BatchSize: 10, MaxBatchBytes: 25},
+		additions: []dnsprovider.ResourceRecordSet{
+			&fakeRecord{name: "a0", rrdatas: []string{"0123456789"}},
+			&fakeRecord{name: "a1", rrdatas: []string{"0123456789"}},
+			&fakeRecord{name: "a2", rrdatas: []string{"0123456789"}},
+		},
+	}
+
+	additions, removals := c.nextBatch(10)
+	if len(removals) != 0 {
+		t.Errorf("got %d removals, want 0", len(removals))
+	}
+	if len(additions) != 2 {
+		t.Errorf("got %d additions in first size-capped batch, want 2", len(additions))
+	}
+	if len(c.additions) != 1 {
+		t.Errorf("got %d pending additions, want 1", len(c.additions))
+	}
+}
+
+func TestBatchedChangesetNextBatchAlwaysMakesProgress(t *testing.T) {
+	c := &batchedChangeset{
+		opts: ChangesetOptions{BatchSize: 10, MaxBatchBytes: 1},
+		additions: []dnsprovider.ResourceRecordSet{
+			&fakeRecord{name: "a0", rrdatas: []string{"0123456789"}},
+			&fakeRecord{name: "a1", rrdatas: []string{"0123456789"}},
+		},
+	}
+
+	additions, _ := c.nextBatch(10)
+	if len(additions) != 1 {
+		t.Errorf("got %d additions, want a single oversized change to still go out", len(additions))
+	}
+}
+
+func TestBatchedChangesetApplyRecordsMetrics(t *testing.T) {
+	rrs := &fakeRRS{}
+	c := &batchedChangeset{
+		rrs:       rrs,
+		opts:      ChangesetOptions{BatchSize: 2},
+		additions: recordsNamed("a", 5),
+	}
+
+	if err := c.Apply(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metrics := c.Metrics()
+	if metrics.ChangeCount != 5 {
+		t.Errorf("got ChangeCount %d, want 5", metrics.ChangeCount)
+	}
+}
+
+func TestBatchedChangesetApplyGivesUpAfterMaxRetries(t *testing.T) {
+	rrs := &fakeRRS{failures: 100}
+	c := &batchedChangeset{
+		rrs:       rrs,
+		opts:      ChangesetOptions{BatchSize: 10, MaxRetries: 2, Backoff: time.Millisecond},
+		additions: recordsNamed("a", 1),
+	}
+
+	if err := c.Apply(); err == nil {
+		t.Errorf("expected an error once MaxRetries is exceeded, got nil")
+	}
+}
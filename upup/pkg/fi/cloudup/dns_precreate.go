@@ -0,0 +1,113 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudup
+
+import (
+	"github.com/golang/glog"
+	api "k8s.io/kops/pkg/apis/kops"
+)
+
+// precreateHostnameProviders is the registry of functions that contribute
+// hostnames to pre-create, in the order they were registered.  Out-of-tree
+// code (and future in-tree subsystems) can add to this list with
+// RegisterPrecreateHostnameProvider instead of editing buildPrecreateDNSHostnames
+// directly.
+var precreateHostnameProviders []func(cluster *api.Cluster) []string
+
+// RegisterPrecreateHostnameProvider adds fn to the list of functions
+// consulted by buildPrecreateDNSHostnames.  fn is given the cluster and
+// should return the (possibly empty) list of DNS hostnames it wants
+// pre-created; it should not return an error for names it doesn't know how
+// to build - just omit them.
+func RegisterPrecreateHostnameProvider(fn func(cluster *api.Cluster) []string) {
+	precreateHostnameProviders = append(precreateHostnameProviders, fn)
+}
+
+func init() {
+	RegisterPrecreateHostnameProvider(masterAndEtcdPrecreateHostnames)
+	RegisterPrecreateHostnameProvider(bastionPrecreateHostnames)
+	RegisterPrecreateHostnameProvider(additionalSANsPrecreateHostnames)
+}
+
+// buildPrecreateDNSHostnames returns the hostnames we should precreate, by
+// consulting every registered provider and de-duplicating the result.
+func buildPrecreateDNSHostnames(cluster *api.Cluster) []string {
+	var dnsHostnames []string
+	seen := make(map[string]bool)
+
+	for _, provider := range precreateHostnameProviders {
+		for _, name := range provider(cluster) {
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			dnsHostnames = append(dnsHostnames, name)
+		}
+	}
+
+	return dnsHostnames
+}
+
+// masterAndEtcdPrecreateHostnames is the original hostname list: the master
+// API names, and one name per etcd cluster member.
+func masterAndEtcdPrecreateHostnames(cluster *api.Cluster) []string {
+	dnsInternalSuffix := ".internal." + cluster.ObjectMeta.Name
+
+	var dnsHostnames []string
+
+	if cluster.Spec.MasterPublicName != "" {
+		dnsHostnames = append(dnsHostnames, cluster.Spec.MasterPublicName)
+	} else {
+		glog.Warningf("cannot pre-create MasterPublicName - not set")
+	}
+
+	if cluster.Spec.MasterInternalName != "" {
+		dnsHostnames = append(dnsHostnames, cluster.Spec.MasterInternalName)
+	} else {
+		glog.Warningf("cannot pre-create MasterInternalName - not set")
+	}
+
+	for _, etcdCluster := range cluster.Spec.EtcdClusters {
+		etcClusterName := "etcd-" + etcdCluster.Name
+		if etcdCluster.Name == "main" {
+			// Special case
+			etcClusterName = "etcd"
+		}
+		for _, etcdClusterMember := range etcdCluster.Members {
+			name := etcClusterName + "-" + etcdClusterMember.Name + dnsInternalSuffix
+			dnsHostnames = append(dnsHostnames, name)
+		}
+	}
+
+	return dnsHostnames
+}
+
+// bastionPrecreateHostnames adds the bastion's public name, if the cluster
+// is configured with a bastion.
+func bastionPrecreateHostnames(cluster *api.Cluster) []string {
+	if cluster.Spec.Topology == nil || cluster.Spec.Topology.Bastion == nil {
+		return nil
+	}
+
+	return []string{"bastion." + cluster.ObjectMeta.Name}
+}
+
+// additionalSANsPrecreateHostnames adds any extra API server hostnames the
+// user declared, so they don't hit a negative-TTL wait on first boot either.
+func additionalSANsPrecreateHostnames(cluster *api.Cluster) []string {
+	return cluster.Spec.AdditionalSANs
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudup
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/federation/pkg/dnsprovider"
+)
+
+func TestIsSubdomain(t *testing.T) {
+	grid := []struct {
+		name     string
+		zoneName string
+		expected bool
+	}{
+		{"mycluster.k8s.example.com", "example.com", true},
+		{"mycluster.k8s.example.com", "k8s.example.com", true},
+		{"mycluster.k8s.example.com", "mycluster.k8s.example.com", false},
+		{"example.com", "example.com", false},
+		{"evilexample.com", "example.com", false},
+		{"mycluster.k8s.example.com", "", false},
+	}
+
+	for _, g := range grid {
+		actual := isSubdomain(g.name, g.zoneName)
+		if actual != g.expected {
+			t.Errorf("isSubdomain(%q, %q) = %v, want %v", g.name, g.zoneName, actual, g.expected)
+		}
+	}
+}
+
+// fakeZone is a minimal dnsprovider.Zone for exercising selectZone without a
+// real cloud DNS backend.
+type fakeZone struct {
+	id   string
+	name string
+}
+
+var _ dnsprovider.Zone = &fakeZone{}
+
+func (z *fakeZone) Name() string { return z.name }
+func (z *fakeZone) ID() string   { return z.id }
+func (z *fakeZone) ResourceRecordSets() (dnsprovider.ResourceRecordSets, bool) {
+	return nil, false
+}
+
+func TestSelectZone_ExactMatch(t *testing.T) {
+	zones := []dnsprovider.Zone{
+		&fakeZone{id: "z1", name: "mycluster.k8s.example.com"},
+		&fakeZone{id: "z2", name: "example.com"},
+	}
+
+	zone, err := selectZone(zones, "mycluster.k8s.example.com", "mycluster.k8s.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if zone.Name() != "mycluster.k8s.example.com" {
+		t.Errorf("got zone %q, want exact match", zone.Name())
+	}
+}
+
+func TestSelectZone_AncestorMatch(t *testing.T) {
+	zones := []dnsprovider.Zone{
+		&fakeZone{id: "z1", name: "example.com"},
+		&fakeZone{id: "z2", name: "unrelated.com"},
+	}
+
+	zone, err := selectZone(zones, "mycluster.k8s.example.com", "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if zone.Name() != "example.com" {
+		t.Errorf("got zone %q, want \"example.com\"", zone.Name())
+	}
+}
+
+func TestSelectZone_PrefersLongestAncestorSuffix(t *testing.T) {
+	zones := []dnsprovider.Zone{
+		&fakeZone{id: "z1", name: "example.com"},
+		&fakeZone{id: "z2", name: "k8s.example.com"},
+	}
+
+	zone, err := selectZone(zones, "mycluster.k8s.example.com", "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if zone.Name() != "k8s.example.com" {
+		t.Errorf("got zone %q, want the more specific \"k8s.example.com\"", zone.Name())
+	}
+}
+
+func TestSelectZone_NoMatch(t *testing.T) {
+	zones := []dnsprovider.Zone{
+		&fakeZone{id: "z1", name: "unrelated.com"},
+	}
+
+	if _, err := selectZone(zones, "mycluster.k8s.example.com", "example.com"); err == nil {
+		t.Errorf("expected an error when no zone matches, got nil")
+	}
+}
+
+func TestSelectZone_AmbiguousExactMatch(t *testing.T) {
+	zones := []dnsprovider.Zone{
+		&fakeZone{id: "z1", name: "example.com"},
+		&fakeZone{id: "z2", name: "example.com"},
+	}
+
+	if _, err := selectZone(zones, "mycluster.example.com", "example.com"); err == nil {
+		t.Errorf("expected an error for multiple exact-matching zones, got nil")
+	}
+}
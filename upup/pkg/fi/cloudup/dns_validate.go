@@ -0,0 +1,323 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudup
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/miekg/dns"
+
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/model"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kubernetes/federation/pkg/dnsprovider"
+)
+
+// defaultDNSValidationResolvers are used to find the parent zone's own
+// authoritative nameservers when cluster.Spec.DNSValidation doesn't name any.
+var defaultDNSValidationResolvers = []string{"8.8.8.8", "1.1.1.1"}
+
+const defaultDNSValidationTimeout = 5 * time.Second
+
+// validateDNS replaces the old DNS_IGNORE_NS_CHECK escape hatch with a real
+// probe: it asks the parent zone's own authoritative servers who they
+// delegate the cluster's zone to, and compares that against the NS records
+// the cloud DNS provider actually serves for the zone.  A mismatch here is
+// the classic "cluster comes up fine but DNS never resolves for users"
+// support case - the hosted zone was created, but the registrar was never
+// pointed at it.
+func validateDNS(cluster *api.Cluster, cloud fi.Cloud) error {
+	kopsModelContext := &model.KopsModelContext{
+		Cluster: cluster,
+		// We are not initializing a lot of the fields here; revisit once UsePrivateDNS is "real"
+	}
+
+	if kopsModelContext.UsePrivateDNS() {
+		glog.Infof("Private DNS: skipping DNS validation")
+		return nil
+	}
+
+	validation := cluster.Spec.DNSValidation
+	if validation != nil && validation.Skip {
+		glog.Infof("DNS validation skipped (spec.dnsValidation.skip is set)")
+		return nil
+	}
+
+	resolvers := defaultDNSValidationResolvers
+	timeout := defaultDNSValidationTimeout
+	strict := false
+	if validation != nil {
+		if len(validation.Resolvers) > 0 {
+			resolvers = validation.Resolvers
+		}
+		if validation.Timeout > 0 {
+			timeout = validation.Timeout
+		}
+		strict = validation.Mode == api.DNSValidationModeStrict
+	}
+
+	zone, err := findZone(cluster, cloud)
+	if err != nil {
+		return err
+	}
+	dnsName := strings.TrimSuffix(zone.Name(), ".")
+
+	childNS, err := cloudZoneNameservers(zone, dnsName)
+	if err != nil {
+		return err
+	}
+
+	delegatedNS, err := authoritativeNameservers(dnsName, resolvers, timeout)
+	if err != nil {
+		return reportDNSValidationProblem(strict, "error querying authoritative nameservers for %q: %v", dnsName, err)
+	}
+
+	if len(delegatedNS) == 0 {
+		return reportDNSValidationProblem(strict, "no delegation NS records found for %q - please make sure the registrar/parent zone has been updated to point at your DNS provider", dnsName)
+	}
+
+	if !sameNameservers(delegatedNS, childNS) {
+		if err := reportDNSValidationProblem(strict, "NS records for %q at the registrar (%v) do not match the zone's own NS records (%v)", dnsName, delegatedNS, childNS); err != nil {
+			return err
+		}
+	} else {
+		glog.V(2).Infof("Confirmed delegation NS records for %q match: %v", dnsName, childNS)
+	}
+
+	if err := verifyDNSSEC(dnsName, resolvers, timeout); err != nil {
+		return reportDNSValidationProblem(strict, "DNSSEC validation failed for %q: %v", dnsName, err)
+	}
+
+	return nil
+}
+
+// reportDNSValidationProblem returns an error in strict mode, or logs a
+// warning and returns nil in permissive mode.
+func reportDNSValidationProblem(strict bool, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if strict {
+		return fmt.Errorf("%s", msg)
+	}
+	glog.Warningf("%s", msg)
+	return nil
+}
+
+// cloudZoneNameservers returns the NS records the cloud DNS provider itself
+// serves at the zone apex (dnsName).  It ignores NS records for any other
+// name in the zone, e.g. a sub-delegation hosted inside the same zone,
+// which would otherwise get folded into "the zone's own nameservers" and
+// produce a bogus comparison against the registrar's delegation.
+func cloudZoneNameservers(zone dnsprovider.Zone, dnsName string) ([]string, error) {
+	rrs, ok := zone.ResourceRecordSets()
+	if !ok {
+		return nil, fmt.Errorf("error getting DNS resource records for %q", zone.Name())
+	}
+
+	records, err := rrs.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing DNS resource records for %q: %v", zone.Name(), err)
+	}
+
+	var hosts []string
+	for _, record := range records {
+		if string(record.Type()) != "NS" {
+			continue
+		}
+		if strings.TrimSuffix(record.Name(), ".") != dnsName {
+			continue
+		}
+		hosts = append(hosts, record.Rrdatas()...)
+	}
+	return hosts, nil
+}
+
+// authoritativeNameservers finds the parent zone's authoritative servers,
+// then queries one of them directly (non-recursively) for the NS records it
+// delegates for name, bypassing any recursive resolver's cache.
+func authoritativeNameservers(name string, resolvers []string, timeout time.Duration) ([]string, error) {
+	parent := parentZone(name)
+	if parent == "" {
+		return nil, fmt.Errorf("cannot determine parent zone of %q", name)
+	}
+
+	parentServers, err := queryNS(parent, resolvers, timeout, true)
+	if err != nil {
+		return nil, fmt.Errorf("error finding authoritative servers for parent zone %q: %v", parent, err)
+	}
+	if len(parentServers) == 0 {
+		return nil, fmt.Errorf("no authoritative servers found for parent zone %q", parent)
+	}
+
+	return queryNS(name, parentServers, timeout, false)
+}
+
+// queryNS asks the given servers, in order, for the NS records of name,
+// returning the first answer with a successful Rcode.  A transport error or
+// a non-success Rcode (e.g. a transiently unsynced or unreachable server
+// answering SERVFAIL/REFUSED) moves on to the next server instead of being
+// treated as the final result; only once every server has failed this way
+// do we give up.  If recurse is false, RD is cleared so that an
+// authoritative server returns its own delegation rather than following it.
+func queryNS(name string, servers []string, timeout time.Duration, recurse bool) ([]string, error) {
+	client := &dns.Client{Timeout: timeout}
+	msg := &dns.Msg{}
+	msg.SetQuestion(dns.Fqdn(name), dns.TypeNS)
+	msg.RecursionDesired = recurse
+
+	var lastErr error
+	for _, server := range servers {
+		resp, _, err := client.Exchange(msg, withDefaultPort(server))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.Rcode != dns.RcodeSuccess {
+			lastErr = fmt.Errorf("server %s returned %s for %q", server, dns.RcodeToString[resp.Rcode], name)
+			continue
+		}
+
+		var names []string
+		for _, rr := range append(resp.Answer, resp.Ns...) {
+			if ns, ok := rr.(*dns.NS); ok {
+				names = append(names, strings.TrimSuffix(ns.Ns, "."))
+			}
+		}
+		return names, nil
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("no usable DNS servers in %v", servers)
+}
+
+// verifyDNSSEC checks, if the parent zone is DNSSEC-signed, that a DS record
+// for name has been registered - otherwise the chain of trust is broken and
+// validating resolvers will refuse to resolve the cluster's records.
+func verifyDNSSEC(name string, resolvers []string, timeout time.Duration) error {
+	parent := parentZone(name)
+	if parent == "" {
+		return nil
+	}
+
+	signed, err := zoneIsDNSSECSigned(parent, resolvers, timeout)
+	if err != nil || !signed {
+		return err
+	}
+
+	ds, err := queryDS(name, resolvers, timeout)
+	if err != nil {
+		return fmt.Errorf("error looking up DS record: %v", err)
+	}
+	if len(ds) == 0 {
+		return fmt.Errorf("parent zone %q is DNSSEC-signed but no DS record was found for %q - the zone's DNSKEY has not been submitted to the registrar", parent, name)
+	}
+	return nil
+}
+
+func zoneIsDNSSECSigned(zoneName string, resolvers []string, timeout time.Duration) (bool, error) {
+	client := &dns.Client{Timeout: timeout}
+	msg := &dns.Msg{}
+	msg.SetQuestion(dns.Fqdn(zoneName), dns.TypeDNSKEY)
+	msg.RecursionDesired = true
+
+	for _, server := range resolvers {
+		resp, _, err := client.Exchange(msg, withDefaultPort(server))
+		if err != nil {
+			continue
+		}
+		for _, rr := range resp.Answer {
+			if _, ok := rr.(*dns.DNSKEY); ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return false, fmt.Errorf("no usable resolvers in %v", resolvers)
+}
+
+func queryDS(name string, resolvers []string, timeout time.Duration) ([]string, error) {
+	client := &dns.Client{Timeout: timeout}
+	msg := &dns.Msg{}
+	msg.SetQuestion(dns.Fqdn(name), dns.TypeDS)
+	msg.RecursionDesired = true
+
+	for _, server := range resolvers {
+		resp, _, err := client.Exchange(msg, withDefaultPort(server))
+		if err != nil {
+			continue
+		}
+		var ds []string
+		for _, rr := range resp.Answer {
+			if rec, ok := rr.(*dns.DS); ok {
+				ds = append(ds, rec.String())
+			}
+		}
+		return ds, nil
+	}
+
+	return nil, fmt.Errorf("no usable resolvers in %v", resolvers)
+}
+
+// parentZone returns name with its leftmost label removed, e.g.
+// "mycluster.k8s.example.com" -> "k8s.example.com".
+func parentZone(name string) string {
+	i := strings.Index(name, ".")
+	if i < 0 {
+		return ""
+	}
+	return name[i+1:]
+}
+
+// sameNameservers compares two NS host lists, ignoring order, case and
+// trailing dots.
+func sameNameservers(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	na := normalizeHosts(a)
+	nb := normalizeHosts(b)
+	if len(na) != len(nb) {
+		return false
+	}
+	for host := range na {
+		if !nb[host] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeHosts(hosts []string) map[string]bool {
+	m := make(map[string]bool)
+	for _, h := range hosts {
+		m[strings.ToLower(strings.TrimSuffix(h, "."))] = true
+	}
+	return m
+}
+
+func withDefaultPort(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, "53")
+}
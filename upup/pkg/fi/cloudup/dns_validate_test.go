@@ -0,0 +1,94 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudup
+
+import "testing"
+
+func TestParentZone(t *testing.T) {
+	grid := []struct {
+		name     string
+		expected string
+	}{
+		{"mycluster.k8s.example.com", "k8s.example.com"},
+		{"k8s.example.com", "example.com"},
+		{"example.com", "com"},
+		{"com", ""},
+		{"", ""},
+	}
+
+	for _, g := range grid {
+		if actual := parentZone(g.name); actual != g.expected {
+			t.Errorf("parentZone(%q) = %q, want %q", g.name, actual, g.expected)
+		}
+	}
+}
+
+func TestSameNameservers(t *testing.T) {
+	grid := []struct {
+		a        []string
+		b        []string
+		expected bool
+	}{
+		{[]string{"ns1.example.com"}, []string{"ns1.example.com"}, true},
+		{[]string{"ns1.example.com."}, []string{"NS1.example.com"}, true},
+		{[]string{"ns1.example.com", "ns2.example.com"}, []string{"ns2.example.com", "ns1.example.com"}, true},
+		{[]string{"ns1.example.com"}, []string{"ns2.example.com"}, false},
+		{[]string{"ns1.example.com"}, []string{"ns1.example.com", "ns2.example.com"}, false},
+		{nil, []string{"ns1.example.com"}, false},
+		{[]string{"ns1.example.com"}, nil, false},
+		{nil, nil, false},
+	}
+
+	for _, g := range grid {
+		if actual := sameNameservers(g.a, g.b); actual != g.expected {
+			t.Errorf("sameNameservers(%v, %v) = %v, want %v", g.a, g.b, actual, g.expected)
+		}
+	}
+}
+
+func TestNormalizeHosts(t *testing.T) {
+	got := normalizeHosts([]string{"NS1.Example.com.", "ns2.example.com", "ns1.example.com"})
+	want := map[string]bool{"ns1.example.com": true, "ns2.example.com": true}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for host := range want {
+		if !got[host] {
+			t.Errorf("got %v, missing %q", got, host)
+		}
+	}
+}
+
+func TestWithDefaultPort(t *testing.T) {
+	grid := []struct {
+		server   string
+		expected string
+	}{
+		{"8.8.8.8", "8.8.8.8:53"},
+		{"8.8.8.8:53", "8.8.8.8:53"},
+		{"8.8.8.8:5353", "8.8.8.8:5353"},
+		{"ns1.example.com", "ns1.example.com:53"},
+		{"[2001:4860:4860::8888]:53", "[2001:4860:4860::8888]:53"},
+	}
+
+	for _, g := range grid {
+		if actual := withDefaultPort(g.server); actual != g.expected {
+			t.Errorf("withDefaultPort(%q) = %q, want %q", g.server, actual, g.expected)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"k8s.io/kops/upup/pkg/fi/cloudup/dns/designate"
+	"k8s.io/kubernetes/federation/pkg/dnsprovider"
+)
+
+// DNS implements fi.Cloud's DNS() method for the OpenStack cloud, so that
+// findZone/validateDNS/precreateDNS in upup/pkg/fi/cloudup/dns.go work
+// unchanged for clusters deployed on OpenStack: they get a Designate-backed
+// dnsprovider.Interface instead of having to know about OpenStack at all.
+func (c *Cloud) DNS() (dnsprovider.Interface, error) {
+	return designate.New()
+}